@@ -17,26 +17,54 @@ limitations under the License.
 package storage
 
 import (
+	"flag"
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8stype "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
 	vsphere "k8s.io/kubernetes/pkg/cloudprovider/providers/vsphere"
 	"k8s.io/kubernetes/test/e2e/framework"
+	imageutils "k8s.io/kubernetes/test/utils/image"
 )
 
 const (
 	Ext4FSType    = "ext4"
 	Ext3FSType    = "ext3"
+	XfsFSType     = "xfs"
 	InvalidFSType = "ext10"
 	ExecCommand   = "/bin/df -T /mnt/volume1 | /bin/awk 'FNR == 2 {print $2}' > /mnt/volume1/fstype && while true ; do sleep 2 ; done"
+	// CSIDriver is the provisioner name of the out-of-tree vSphere CSI driver.
+	CSIDriver = "csi.vsphere.vmware.com"
+	// RawBlockDevicePath is the device path a Block volumeMode PVC is
+	// exposed at inside the pod consuming it.
+	RawBlockDevicePath = "/dev/xvda"
+	// BlockVolumeProbeCommand just keeps the pod alive; unlike ExecCommand it
+	// does not probe the volume at start-up, since the blkid check a Block
+	// volumeMode PVC needs is run against the live pod afterwards, in
+	// verifyNoFilesystemOnBlockVolume.
+	BlockVolumeProbeCommand = "while true ; do sleep 2 ; done"
+	resizePollInterval      = 5 * time.Second
+	resizeTimeout           = 5 * time.Minute
+	// initialVolumeSizeKB mirrors the "2Gi" PVC request size createVolume
+	// uses, expressed in the KB units df -T reports, so the online-resize
+	// test can assert the in-pod filesystem actually grew past it.
+	initialVolumeSizeKB = 2 * 1024 * 1024
 )
 
+// useVsphereCSIDriver, when set, runs the fstype suite against the external
+// vSphere CSI driver instead of the in-tree vsphere cloud provider.
+var useVsphereCSIDriver = flag.Bool("vsphere-csi-driver", false, "run the vSphere Volume FStype e2e suite against the out-of-tree CSI driver instead of the in-tree provider")
+
 /*
 	Test to verify fstype specified in storage-class is being honored after volume creation.
 
@@ -61,8 +89,113 @@ const (
 	5. Create pod using PVC.
 	6. Verify if the pod creation fails.
 	7. Verify if the MountVolume.MountDevice fails because it is unable to find the file system executable file on the node.
+
+	These tests run against either the in-tree vsphere cloud provider or the
+	out-of-tree csi.vsphere.vmware.com CSI driver, selected via the
+	-vsphere-csi-driver flag, so both migration paths stay covered by the
+	same scenarios.
 */
 
+// vsphereVolumeProvider abstracts the attach/detach verification calls that
+// differ between the in-tree vsphere cloud provider and the out-of-tree CSI
+// driver, so invokeTestForFstype/invokeTestForInvalidFstype can drive either.
+type vsphereVolumeProvider interface {
+	VerifyVolumesAccessible(pod *v1.Pod, persistentvolumes []*v1.PersistentVolume)
+	WaitForVolumeDetach(persistentvolumes []*v1.PersistentVolume, nodeName k8stype.NodeName)
+}
+
+// vsphereInTreeProvider drives attach/detach verification through the
+// VirtualDiskManager calls made by the in-tree vsphere cloud provider.
+type vsphereInTreeProvider struct {
+	vsp *vsphere.VSphere
+}
+
+func (p *vsphereInTreeProvider) VerifyVolumesAccessible(pod *v1.Pod, persistentvolumes []*v1.PersistentVolume) {
+	verifyVSphereVolumesAccessible(pod, persistentvolumes, p.vsp)
+}
+
+func (p *vsphereInTreeProvider) WaitForVolumeDetach(persistentvolumes []*v1.PersistentVolume, nodeName k8stype.NodeName) {
+	waitForVSphereDiskToDetach(p.vsp, persistentvolumes[0].Spec.VsphereVolume.VolumePath, nodeName)
+}
+
+// vsphereCSIProvider drives attach/detach verification through the
+// VolumeAttachment objects created by the external CSI driver rather than
+// reaching into the in-tree VirtualDiskManager. A CSI-provisioned PV carries
+// its identity in Spec.CSI, not Spec.VsphereVolume, so it is looked up by PV
+// name rather than by VsphereVolume.VolumePath.
+type vsphereCSIProvider struct {
+	client clientset.Interface
+}
+
+func (p *vsphereCSIProvider) VerifyVolumesAccessible(pod *v1.Pod, persistentvolumes []*v1.PersistentVolume) {
+	verifyVSphereVolumesAttachedViaCSI(p.client, pod, persistentvolumes)
+}
+
+func (p *vsphereCSIProvider) WaitForVolumeDetach(persistentvolumes []*v1.PersistentVolume, nodeName k8stype.NodeName) {
+	waitForCSIVolumeAttachmentToDetach(p.client, persistentvolumes[0].Name, nodeName)
+}
+
+// getVSphereVolumeProvider returns the vsphereVolumeProvider the suite should
+// exercise for this run, honoring the -vsphere-csi-driver flag.
+func getVSphereVolumeProvider(client clientset.Interface) (vsphereVolumeProvider, error) {
+	if *useVsphereCSIDriver {
+		return &vsphereCSIProvider{client: client}, nil
+	}
+	vsp, err := vsphere.GetVSphere()
+	if err != nil {
+		return nil, err
+	}
+	return &vsphereInTreeProvider{vsp: vsp}, nil
+}
+
+// getCSIVolumeAttachmentForPV returns the VolumeAttachment the CSI driver
+// created for pvName, or nil if none exists (yet, or any more).
+func getCSIVolumeAttachmentForPV(client clientset.Interface, pvName string) (*storagev1.VolumeAttachment, error) {
+	attachments, err := client.StorageV1().VolumeAttachments().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range attachments.Items {
+		attachment := &attachments.Items[i]
+		if attachment.Spec.Source.PersistentVolumeName != nil && *attachment.Spec.Source.PersistentVolumeName == pvName {
+			return attachment, nil
+		}
+	}
+	return nil, nil
+}
+
+// verifyVSphereVolumesAttachedViaCSI confirms the CSI driver attached each of
+// persistentvolumes by polling for a VolumeAttachment with status.attached
+// true, the CSI counterpart to verifyVSphereVolumesAccessible's in-tree
+// VirtualDiskManager lookup.
+func verifyVSphereVolumesAttachedViaCSI(client clientset.Interface, pod *v1.Pod, persistentvolumes []*v1.PersistentVolume) {
+	for _, pv := range persistentvolumes {
+		By(fmt.Sprintf("Verifying CSI VolumeAttachment reports volume %s as attached", pv.Name))
+		err := wait.PollImmediate(resizePollInterval, resizeTimeout, func() (bool, error) {
+			attachment, err := getCSIVolumeAttachmentForPV(client, pv.Name)
+			if err != nil {
+				return false, err
+			}
+			return attachment != nil && attachment.Status.Attached, nil
+		})
+		Expect(err).NotTo(HaveOccurred(), "CSI VolumeAttachment for volume %s never reported attached", pv.Name)
+	}
+}
+
+// waitForCSIVolumeAttachmentToDetach polls until the CSI driver has removed
+// the VolumeAttachment for pvName, the CSI counterpart to
+// waitForVSphereDiskToDetach's in-tree VirtualDiskManager polling.
+func waitForCSIVolumeAttachmentToDetach(client clientset.Interface, pvName string, nodeName k8stype.NodeName) {
+	err := wait.PollImmediate(resizePollInterval, resizeTimeout, func() (bool, error) {
+		attachment, err := getCSIVolumeAttachmentForPV(client, pvName)
+		if err != nil {
+			return false, err
+		}
+		return attachment == nil, nil
+	})
+	Expect(err).NotTo(HaveOccurred(), "CSI VolumeAttachment for volume %s on node %s was not removed", pvName, nodeName)
+}
+
 var _ = SIGDescribe("Volume FStype [Feature:vsphere]", func() {
 	f := framework.NewDefaultFramework("volume-fstype")
 	var (
@@ -91,38 +224,63 @@ var _ = SIGDescribe("Volume FStype [Feature:vsphere]", func() {
 		By("Invoking Test for fstype: invalid Value")
 		invokeTestForInvalidFstype(f, client, namespace, InvalidFSType)
 	})
+
+	It("verify fstype - xfs formatted volume", func() {
+		By("Invoking Test for fstype: xfs")
+		invokeTestForFstype(f, client, namespace, XfsFSType, XfsFSType)
+	})
+
+	It("verify volume mode - Block volume has no filesystem regardless of storage class fstype", func() {
+		By("Invoking Test for Block volumeMode with fstype set")
+		invokeTestForBlockVolume(f, client, namespace, Ext4FSType)
+	})
+
+	It("verify online resize honors fstype - ext3", func() {
+		By("Invoking Test for online resize with fstype: ext3")
+		invokeTestForOnlineResize(f, client, namespace, Ext3FSType, Ext3FSType)
+	})
+
+	It("verify online resize honors fstype - default value ext4", func() {
+		By("Invoking Test for online resize with fstype: Default Value - ext4")
+		invokeTestForOnlineResize(f, client, namespace, "", Ext4FSType)
+	})
+
+	It("verify online resize honors fstype - xfs", func() {
+		By("Invoking Test for online resize with fstype: xfs")
+		invokeTestForOnlineResize(f, client, namespace, XfsFSType, XfsFSType)
+	})
 })
 
 func invokeTestForFstype(f *framework.Framework, client clientset.Interface, namespace string, fstype string, expectedContent string) {
 	framework.Logf("Invoking Test for fstype: %s", fstype)
 	scParameters := make(map[string]string)
 	scParameters["fstype"] = fstype
-	vsp, err := vsphere.GetVSphere()
+	provider, err := getVSphereVolumeProvider(client)
 	Expect(err).NotTo(HaveOccurred())
 
 	// Create Persistent Volume
 	By("Creating Storage Class With Fstype")
-	pvclaim, persistentvolumes := createVolume(client, namespace, scParameters)
+	pvclaim, persistentvolumes := createVolume(client, namespace, scParameters, nil, false)
 
 	// Create Pod and verify the persistent volume is accessible
-	pod := createPodAndVerifyVolumeAccessible(client, namespace, pvclaim, persistentvolumes, vsp)
+	pod := createPodAndVerifyVolumeAccessible(client, namespace, pvclaim, persistentvolumes, provider)
 	_, err = framework.LookForStringInPodExec(namespace, pod.Name, []string{"/bin/cat", "/mnt/volume1/fstype"}, expectedContent, time.Minute)
 	Expect(err).NotTo(HaveOccurred())
 
 	// Detach and delete volume
-	detachVolume(f, client, vsp, pod, persistentvolumes[0].Spec.VsphereVolume.VolumePath)
+	detachVolume(f, client, provider, pod, persistentvolumes)
 	deleteVolume(client, pvclaim.Name, namespace)
 }
 
 func invokeTestForInvalidFstype(f *framework.Framework, client clientset.Interface, namespace string, fstype string) {
 	scParameters := make(map[string]string)
 	scParameters["fstype"] = fstype
-	vsp, err := vsphere.GetVSphere()
+	provider, err := getVSphereVolumeProvider(client)
 	Expect(err).NotTo(HaveOccurred())
 
 	// Create Persistent Volume
 	By("Creating Storage Class With Invalid Fstype")
-	pvclaim, persistentvolumes := createVolume(client, namespace, scParameters)
+	pvclaim, persistentvolumes := createVolume(client, namespace, scParameters, nil, false)
 
 	By("Creating pod to attach PV to the node")
 	var pvclaims []*v1.PersistentVolumeClaim
@@ -134,7 +292,7 @@ func invokeTestForInvalidFstype(f *framework.Framework, client clientset.Interfa
 	eventList, err := client.CoreV1().Events(namespace).List(metav1.ListOptions{})
 
 	// Detach and delete volume
-	detachVolume(f, client, vsp, pod, persistentvolumes[0].Spec.VsphereVolume.VolumePath)
+	detachVolume(f, client, provider, pod, persistentvolumes)
 	deleteVolume(client, pvclaim.Name, namespace)
 
 	Expect(eventList.Items).NotTo(BeEmpty())
@@ -148,13 +306,171 @@ func invokeTestForInvalidFstype(f *framework.Framework, client clientset.Interfa
 	Expect(isFound).To(BeTrue(), "Unable to verify MountVolume.MountDevice failure")
 }
 
-func createVolume(client clientset.Interface, namespace string, scParameters map[string]string) (*v1.PersistentVolumeClaim, []*v1.PersistentVolume) {
-	storageclass, err := client.StorageV1().StorageClasses().Create(getVSphereStorageClassSpec("fstype", scParameters))
+// invokeTestForBlockVolume verifies that a Block volumeMode PVC comes up
+// with no filesystem at all, regardless of the fstype parameter set on its
+// storage class.
+func invokeTestForBlockVolume(f *framework.Framework, client clientset.Interface, namespace string, fstype string) {
+	framework.Logf("Invoking Test for Block volumeMode, fstype: %s", fstype)
+	scParameters := make(map[string]string)
+	scParameters["fstype"] = fstype
+	provider, err := getVSphereVolumeProvider(client)
+	Expect(err).NotTo(HaveOccurred())
+
+	blockVolumeMode := v1.PersistentVolumeBlock
+	By("Creating Storage Class With Fstype And Block VolumeMode PVC")
+	pvclaim, persistentvolumes := createVolume(client, namespace, scParameters, &blockVolumeMode, false)
+
+	By("Creating pod to consume the raw block volume")
+	var pvclaims []*v1.PersistentVolumeClaim
+	pvclaims = append(pvclaims, pvclaim)
+	pod, err := createPodWithBlockVolume(client, namespace, pvclaims)
+	Expect(err).NotTo(HaveOccurred())
+
+	By("Verify the volume is accessible and available in the pod")
+	provider.VerifyVolumesAccessible(pod, persistentvolumes)
+
+	By("Verify no filesystem was created on the raw block device")
+	verifyNoFilesystemOnBlockVolume(namespace, pod.Name)
+
+	// Detach and delete volume
+	detachVolume(f, client, provider, pod, persistentvolumes)
+	deleteVolume(client, pvclaim.Name, namespace)
+}
+
+// invokeTestForOnlineResize verifies that expanding a bound PVC while its
+// pod is running grows the volume without disturbing the fstype the
+// storage class originally provisioned it with.
+func invokeTestForOnlineResize(f *framework.Framework, client clientset.Interface, namespace string, fstype string, expectedContent string) {
+	framework.Logf("Invoking Test for online resize, fstype: %s", fstype)
+	scParameters := make(map[string]string)
+	scParameters["fstype"] = fstype
+	provider, err := getVSphereVolumeProvider(client)
+	Expect(err).NotTo(HaveOccurred())
+
+	By("Creating expandable Storage Class With Fstype")
+	pvclaim, persistentvolumes := createVolume(client, namespace, scParameters, nil, true)
+
+	pod := createPodAndVerifyVolumeAccessible(client, namespace, pvclaim, persistentvolumes, provider)
+	_, err = framework.LookForStringInPodExec(namespace, pod.Name, []string{"/bin/cat", "/mnt/volume1/fstype"}, expectedContent, time.Minute)
+	Expect(err).NotTo(HaveOccurred())
+
+	By("Expanding the bound PVC from 2Gi to 4Gi while the pod is running")
+	newSize := resource.MustParse("4Gi")
+	expandPVCAndWaitForFileSystemResize(client, namespace, pvclaim, newSize)
+
+	By("Verify online resize honors fstype")
+	verifyResizedVolumeFstype(namespace, pod.Name, expectedContent)
+
+	// Detach and delete volume
+	detachVolume(f, client, provider, pod, persistentvolumes)
+	deleteVolume(client, pvclaim.Name, namespace)
+}
+
+// expandPVCAndWaitForFileSystemResize patches pvclaim's requested storage to
+// newSize and waits for the PVC to report a status.capacity at least that
+// large. It first waits for the FileSystemResizePending condition set by the
+// kubelet's volume expansion reconciliation, but only logs it if that
+// condition is never observed; the capacity check alone decides the outcome.
+func expandPVCAndWaitForFileSystemResize(client clientset.Interface, namespace string, pvclaim *v1.PersistentVolumeClaim, newSize resource.Quantity) {
+	pvc, err := client.CoreV1().PersistentVolumeClaims(namespace).Get(pvclaim.Name, metav1.GetOptions{})
+	Expect(err).NotTo(HaveOccurred())
+
+	pvc.Spec.Resources.Requests[v1.ResourceStorage] = newSize
+	_, err = client.CoreV1().PersistentVolumeClaims(namespace).Update(pvc)
+	Expect(err).NotTo(HaveOccurred())
+
+	// The resize can complete fast enough that FileSystemResizePending comes
+	// and goes between polls, so its absence here isn't itself a failure -
+	// the capacity check below is the authoritative signal that resize
+	// finished.
+	By("Waiting for PVC status.conditions to report FileSystemResizePending")
+	if err := waitForPVCResizeCondition(client, namespace, pvclaim.Name, v1.PersistentVolumeClaimFileSystemResizePending); err != nil {
+		framework.Logf("did not observe FileSystemResizePending for PVC %s: %v", pvclaim.Name, err)
+	}
+
+	By("Waiting for PVC status.capacity to reflect the new size")
+	err = waitForPVCCapacity(client, namespace, pvclaim.Name, newSize)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+// waitForPVCResizeCondition polls until pvcName carries condType in its
+// status.conditions, or the resize timeout elapses. Resize can complete
+// fast enough that the condition comes and goes between polls, so a poll
+// that never observes it is tolerated as long as the capacity check that
+// follows still succeeds.
+func waitForPVCResizeCondition(client clientset.Interface, namespace string, pvcName string, condType v1.PersistentVolumeClaimConditionType) error {
+	return wait.PollImmediate(resizePollInterval, resizeTimeout, func() (bool, error) {
+		pvc, err := client.CoreV1().PersistentVolumeClaims(namespace).Get(pvcName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, cond := range pvc.Status.Conditions {
+			if cond.Type == condType {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+// waitForPVCCapacity polls until pvcName's status.capacity is at least
+// expectedSize, or the resize timeout elapses.
+func waitForPVCCapacity(client clientset.Interface, namespace string, pvcName string, expectedSize resource.Quantity) error {
+	return wait.PollImmediate(resizePollInterval, resizeTimeout, func() (bool, error) {
+		pvc, err := client.CoreV1().PersistentVolumeClaims(namespace).Get(pvcName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		actual, ok := pvc.Status.Capacity[v1.ResourceStorage]
+		if !ok {
+			return false, nil
+		}
+		return actual.Cmp(expectedSize) >= 0, nil
+	})
+}
+
+// verifyResizedVolumeFstype re-runs the df -T probe against the already
+// running pod to confirm both that the volume kept its original fstype and
+// that the filesystem itself grew past its pre-resize size, after being
+// expanded. Checking PVC status.capacity alone (as waitForPVCCapacity does)
+// only proves the vSphere disk grew; it says nothing about whether the
+// filesystem on top of it was actually resized.
+func verifyResizedVolumeFstype(namespace string, podName string, expectedFstype string) {
+	dfCmd := "/bin/df -T /mnt/volume1 | /bin/awk 'FNR == 2 {print $2, $3}'"
+	output, err := framework.RunKubectl("exec", podName, fmt.Sprintf("--namespace=%s", namespace), "--", "/bin/sh", "-c", dfCmd)
+	Expect(err).NotTo(HaveOccurred())
+
+	fields := strings.Fields(strings.TrimSpace(output))
+	Expect(fields).To(HaveLen(2), "unexpected df -T output: %q", output)
+
+	Expect(fields[0]).To(Equal(expectedFstype), "expected volume to remain formatted as %s after online resize, df -T reported: %q", expectedFstype, output)
+
+	actualSizeKB, err := strconv.ParseInt(fields[1], 10, 64)
+	Expect(err).NotTo(HaveOccurred(), "could not parse df -T 1K-blocks size from output: %q", output)
+	Expect(actualSizeKB).To(BeNumerically(">", initialVolumeSizeKB), "expected filesystem on %s to grow past its pre-resize size of %dKB, df -T reported %dKB", podName, initialVolumeSizeKB, actualSizeKB)
+}
+
+func createVolume(client clientset.Interface, namespace string, scParameters map[string]string, volumeMode *v1.PersistentVolumeMode, allowVolumeExpansion bool) (*v1.PersistentVolumeClaim, []*v1.PersistentVolume) {
+	var sc *storagev1.StorageClass
+	if *useVsphereCSIDriver {
+		sc = getVSphereCSIStorageClassSpec("fstype", scParameters)
+	} else {
+		sc = getVSphereStorageClassSpec("fstype", scParameters)
+	}
+	if allowVolumeExpansion {
+		allowExpansion := true
+		sc.AllowVolumeExpansion = &allowExpansion
+	}
+	storageclass, err := client.StorageV1().StorageClasses().Create(sc)
 	Expect(err).NotTo(HaveOccurred())
 	defer client.StorageV1().StorageClasses().Delete(storageclass.Name, nil)
 
 	By("Creating PVC using the Storage Class")
-	pvclaim, err := client.CoreV1().PersistentVolumeClaims(namespace).Create(getVSphereClaimSpecWithStorageClassAnnotation(namespace, "2Gi", storageclass))
+	claimSpec := getVSphereClaimSpecWithStorageClassAnnotation(namespace, "2Gi", storageclass)
+	if volumeMode != nil {
+		claimSpec.Spec.VolumeMode = volumeMode
+	}
+	pvclaim, err := client.CoreV1().PersistentVolumeClaims(namespace).Create(claimSpec)
 	Expect(err).NotTo(HaveOccurred())
 
 	var pvclaims []*v1.PersistentVolumeClaim
@@ -165,7 +481,83 @@ func createVolume(client clientset.Interface, namespace string, scParameters map
 	return pvclaim, persistentvolumes
 }
 
-func createPodAndVerifyVolumeAccessible(client clientset.Interface, namespace string, pvclaim *v1.PersistentVolumeClaim, persistentvolumes []*v1.PersistentVolume, vsp *vsphere.VSphere) *v1.Pod {
+// createPodWithBlockVolume creates a pod that consumes pvclaims as raw block
+// devices via VolumeDevices rather than VolumeMounts, since framework.CreatePod
+// only wires up filesystem-mode mounts.
+func createPodWithBlockVolume(client clientset.Interface, namespace string, pvclaims []*v1.PersistentVolumeClaim) (*v1.Pod, error) {
+	var volumes []v1.Volume
+	var volumeDevices []v1.VolumeDevice
+	for i, pvclaim := range pvclaims {
+		volumeName := fmt.Sprintf("volume%d", i+1)
+		volumes = append(volumes, v1.Volume{
+			Name: volumeName,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pvclaim.Name,
+				},
+			},
+		})
+		volumeDevices = append(volumeDevices, v1.VolumeDevice{
+			Name:       volumeName,
+			DevicePath: RawBlockDevicePath,
+		})
+	}
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "vsphere-block-volume-tester-",
+			Namespace:    namespace,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:          "vsphere-block-volume-tester",
+					Image:         imageutils.GetE2EImage(imageutils.BusyBox),
+					Command:       []string{"/bin/sh", "-c", BlockVolumeProbeCommand},
+					VolumeDevices: volumeDevices,
+				},
+			},
+			RestartPolicy: v1.RestartPolicyNever,
+			Volumes:       volumes,
+		},
+	}
+
+	pod, err := client.CoreV1().Pods(namespace).Create(pod)
+	if err != nil {
+		return nil, err
+	}
+	if err := framework.WaitForPodNameRunningInNamespace(client, pod.Name, namespace); err != nil {
+		return nil, err
+	}
+	return client.CoreV1().Pods(namespace).Get(pod.Name, metav1.GetOptions{})
+}
+
+// verifyNoFilesystemOnBlockVolume execs blkid against the raw block device
+// consumed by podName and asserts it reports no filesystem, i.e. the fstype
+// storage class parameter was correctly ignored for a Block volumeMode PVC.
+func verifyNoFilesystemOnBlockVolume(namespace string, podName string) {
+	// blkid exits 2 when it finds no filesystem signature at all, which is
+	// exactly the state a Block volumeMode PVC is expected to be in, so
+	// only that exit code (besides a clean 0) is tolerated here - anything
+	// else (missing binary, wrong device path, ...) still fails the test.
+	blkidCmd := fmt.Sprintf("/sbin/blkid %s; rc=$?; [ $rc -eq 0 ] || [ $rc -eq 2 ]", RawBlockDevicePath)
+	output, err := framework.RunKubectl("exec", podName, fmt.Sprintf("--namespace=%s", namespace), "--", "/bin/sh", "-c", blkidCmd)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(strings.TrimSpace(output)).To(BeEmpty(), "expected raw block device %s to have no filesystem, blkid reported: %q", RawBlockDevicePath, output)
+}
+
+// getVSphereCSIStorageClassSpec mirrors getVSphereStorageClassSpec but
+// provisions through the out-of-tree CSI driver instead of the in-tree
+// kubernetes.io/vsphere-volume provisioner. The CSI driver accepts the same
+// "fstype" StorageClass parameter as the in-tree provisioner, so scParameters
+// is passed through unchanged.
+func getVSphereCSIStorageClassSpec(name string, scParameters map[string]string) *storagev1.StorageClass {
+	sc := getVSphereStorageClassSpec(name, scParameters)
+	sc.Provisioner = CSIDriver
+	return sc
+}
+
+func createPodAndVerifyVolumeAccessible(client clientset.Interface, namespace string, pvclaim *v1.PersistentVolumeClaim, persistentvolumes []*v1.PersistentVolume, provider vsphereVolumeProvider) *v1.Pod {
 	var pvclaims []*v1.PersistentVolumeClaim
 	pvclaims = append(pvclaims, pvclaim)
 	By("Creating pod to attach PV to the node")
@@ -175,16 +567,16 @@ func createPodAndVerifyVolumeAccessible(client clientset.Interface, namespace st
 
 	// Asserts: Right disk is attached to the pod
 	By("Verify the volume is accessible and available in the pod")
-	verifyVSphereVolumesAccessible(pod, persistentvolumes, vsp)
+	provider.VerifyVolumesAccessible(pod, persistentvolumes)
 	return pod
 }
 
-func detachVolume(f *framework.Framework, client clientset.Interface, vsp *vsphere.VSphere, pod *v1.Pod, volPath string) {
+func detachVolume(f *framework.Framework, client clientset.Interface, provider vsphereVolumeProvider, pod *v1.Pod, persistentvolumes []*v1.PersistentVolume) {
 	By("Deleting pod")
 	framework.DeletePodWithWait(f, client, pod)
 
 	By("Waiting for volumes to be detached from the node")
-	waitForVSphereDiskToDetach(vsp, volPath, k8stype.NodeName(pod.Spec.NodeName))
+	provider.WaitForVolumeDetach(persistentvolumes, k8stype.NodeName(pod.Spec.NodeName))
 }
 
 func deleteVolume(client clientset.Interface, pvclaimName string, namespace string) {